@@ -13,6 +13,10 @@ func New(a *csfs.App) *cobra.Command {
 	var exclude []string
 	var deleteFiles bool
 	var watch []string
+	var conflictMode string
+	var transport string
+	var logFile string
+	var logJSON bool
 
 	cmd := &cobra.Command{
 		Use:           "csfs",
@@ -26,11 +30,15 @@ Additionally, csfs requires the GitHub command-line tool (gh) and rsync to be in
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts := csfs.AppOptions{
-				Codespace:   codespace,
-				Workspace:   workspace,
-				Exclude:     exclude,
-				DeleteFiles: deleteFiles,
-				Watch:       watch,
+				Codespace:    codespace,
+				Workspace:    workspace,
+				Exclude:      exclude,
+				DeleteFiles:  deleteFiles,
+				Watch:        watch,
+				ConflictMode: csfs.ConflictMode(conflictMode),
+				Transport:    csfs.TransportKind(transport),
+				LogFile:      logFile,
+				LogJSON:      logJSON,
 			}
 
 			return a.Run(cmd.Context(), opts)
@@ -42,6 +50,12 @@ Additionally, csfs requires the GitHub command-line tool (gh) and rsync to be in
 	cmd.Flags().StringSliceVarP(&exclude, "exclude", "e", []string{}, "exclude files matching pattern")
 	cmd.Flags().BoolVarP(&deleteFiles, "delete", "d", false, "delete files that don't exist in the codespace")
 	cmd.Flags().StringSliceVarP(&watch, "watch", "W", []string{}, "watch files matching pattern")
+	cmd.Flags().StringVar(&conflictMode, "conflict-mode", string(csfs.ConflictModeNewerWins), "how to resolve files changed on both sides (prefer-local, prefer-remote, newer-wins, rename, abort)")
+	cmd.Flags().StringVar(&transport, "transport", string(csfs.TransportRsync), "sync transport to use (rsync, sftp)")
+	cmd.Flags().StringVar(&logFile, "log-file", "", "write structured logs to this file instead of discarding them")
+	cmd.Flags().BoolVar(&logJSON, "log-json", false, "write logs as JSON instead of text (requires --log-file)")
+
+	cmd.AddCommand(newConfigSSHCmd(a))
 
 	return cmd
 }
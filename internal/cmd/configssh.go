@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/josebalius/gh-csfs/internal/csfs"
+	"github.com/spf13/cobra"
+)
+
+func newConfigSSHCmd(a *csfs.App) *cobra.Command {
+	var codespace string
+	var remove bool
+
+	cmd := &cobra.Command{
+		Use:   "config-ssh",
+		Short: "Add or remove an SSH config entry for a codespace",
+		Long: `config-ssh writes a managed Host block into ~/.ssh/config for the resolved
+codespace so that standard SSH tooling (VSCode Remote-SSH, rsync, scp, sshfs)
+can connect to it directly, without going through "gh cs ssh".`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.ConfigSSH(cmd.Context(), codespace, remove)
+		},
+	}
+
+	cmd.Flags().StringVarP(&codespace, "codespace", "c", "", "codespace to use")
+	cmd.Flags().BoolVar(&remove, "remove", false, "remove the csfs entry instead of adding it")
+
+	return cmd
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,11 +19,15 @@ import (
 var errInterrupt = errors.New("interrupted")
 
 type AppOptions struct {
-	Codespace   string
-	Workspace   string
-	Exclude     []string
-	DeleteFiles bool
-	Watch       []string
+	Codespace    string
+	Workspace    string
+	Exclude      []string
+	DeleteFiles  bool
+	Watch        []string
+	ConflictMode ConflictMode
+	Transport    TransportKind
+	LogFile      string
+	LogJSON      bool
 }
 
 // App is the main application for csfs. It manages the user interaction
@@ -46,6 +51,12 @@ func (a *App) Run(
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	closeLog, err := a.setupLogger(opts.LogFile, opts.LogJSON)
+	if err != nil {
+		return fmt.Errorf("setup logger failed: %w", err)
+	}
+	defer closeLog()
+
 	errch := make(chan error, 3) // sshServer, watcher, syncer
 	defer close(errch)
 
@@ -108,7 +119,7 @@ func (a *App) Run(
 	// Setup sync operations.
 	var workspaceExists bool
 	err = a.op("Setting up sync opertions", func() error {
-		a.syncer, workspaceExists, err = a.setupSyncer(conn, opts.Workspace, opts.Exclude)
+		a.syncer, workspaceExists, err = a.setupSyncer(conn, opts.Workspace, opts.Exclude, opts.ConflictMode, opts.Transport)
 		return err
 	})
 	if err != nil {
@@ -120,6 +131,23 @@ func (a *App) Run(
 		}
 	}()
 
+	// server.Ready() emits again whenever the SSH tunnel reconnects after a
+	// drop; keep the syncer pointed at the current port so syncs don't keep
+	// failing against a dead tunnel.
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case conn := <-server.Ready():
+				if err := a.syncer.UpdatePort(conn.Port); err != nil {
+					errch <- fmt.Errorf("update port failed: %w", err)
+					return
+				}
+			}
+		}
+	}()
+
 	// Sync the workspace dir to the current directory. This sync omits
 	// the .git directory.
 	if err := a.initialSync(ctx, workspaceExists, opts.DeleteFiles); err != nil {
@@ -183,7 +211,9 @@ func (a *App) initialSync(ctx context.Context, workspaceExists, deleteFiles bool
 	})
 }
 
-func (a *App) setupSyncer(conn sshServerConn, workspace string, exclude []string) (*syncer, bool, error) {
+func (a *App) setupSyncer(
+	conn sshServerConn, workspace string, exclude []string, conflictMode ConflictMode, transportKind TransportKind,
+) (*syncer, bool, error) {
 	codespaceDir := fmt.Sprintf("%s@localhost:/workspaces/%s", conn.Username, workspace)
 	wd, err := os.Getwd()
 	if err != nil {
@@ -202,7 +232,12 @@ func (a *App) setupSyncer(conn sshServerConn, workspace string, exclude []string
 	if len(exclude) > 0 {
 		excludes = append(excludes, exclude...)
 	}
-	a.syncer = newSyncer(conn.Port, localDir, codespaceDir, excludes, 500*time.Millisecond)
+	a.syncer, err = newSyncer(
+		conn.Port, localDir, codespaceDir, excludes, 500*time.Millisecond, conflictMode, transportKind, conn.Username,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("new syncer failed: %w", err)
+	}
 	return a.syncer, workspaceExists, nil
 }
 
@@ -210,6 +245,7 @@ const availableCommands = `
 Available commands:
  s = sync to local
  d = sync to local w/ deletion
+ c = list unresolved conflicts
  q = quit
 `
 
@@ -229,6 +265,20 @@ func (a *App) processKeyEvent(ctx context.Context, exit chan struct{}, e keyboar
 			return fmt.Errorf("enter println failed: %w", err)
 		}
 	}
+	if e.Rune == 'c' {
+		a.outputmu.Lock()
+		defer a.outputmu.Unlock()
+
+		conflicts := a.syncer.UnresolvedConflicts()
+		if len(conflicts) == 0 {
+			fmt.Println("No unresolved conflicts")
+			return nil
+		}
+		fmt.Println("Unresolved conflicts:")
+		for _, path := range conflicts {
+			fmt.Printf(" - %s\n", path)
+		}
+	}
 	if e.Rune == 's' || e.Rune == 'd' {
 		var withDeletion bool
 		op := "Syncing codespace to local"
@@ -247,14 +297,7 @@ func (a *App) processKeyEvent(ctx context.Context, exit chan struct{}, e keyboar
 }
 
 func (a *App) showSync(e syncType) error {
-	a.outputmu.Lock()
-	defer a.outputmu.Unlock()
-
-	// TODO(josebalius): Figure out how to not to collide with the spinner.
-	syncRecord := fmt.Sprintf("[INFO][%s] Synced to %s\n", time.Now().Format(time.RFC1123), e)
-	if _, err := fmt.Fprintf(os.Stdout, syncRecord); err != nil {
-		return err
-	}
+	log.Info(CategorySyncer, "synced", "type", e.String())
 	return nil
 }
 
@@ -330,10 +373,31 @@ func (a *App) op(msg string, fn func() error) error {
 	a.outputmu.Lock()
 	defer a.outputmu.Unlock()
 
+	log.Debug(CategoryUI, msg)
+
 	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
 	s.Suffix = fmt.Sprintf(" %s", msg)
 	s.Start()
 	defer s.Stop()
 
-	return fn()
+	err := fn()
+	if err != nil {
+		log.Error(CategoryUI, "operation failed", "op", msg, "error", err)
+	}
+	return err
+}
+
+// setupLogger points the package-wide Logger at --log-file (or discards
+// logs entirely if unset) and returns a cleanup func to close the file.
+func (a *App) setupLogger(logFile string, jsonOutput bool) (func(), error) {
+	if logFile == "" {
+		SetLogger(NewLogger(io.Discard, jsonOutput))
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open log file failed: %w", err)
+	}
+	SetLogger(NewLogger(f, jsonOutput))
+	return func() { f.Close() }, nil
 }
@@ -0,0 +1,197 @@
+package csfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConflictMode controls how the syncer resolves a file that changed on both
+// the local filesystem and the codespace since the last successful sync.
+type ConflictMode string
+
+const (
+	// ConflictModePreferLocal keeps the local copy and overwrites the remote one.
+	ConflictModePreferLocal ConflictMode = "prefer-local"
+	// ConflictModePreferRemote keeps the codespace copy and overwrites the local one.
+	ConflictModePreferRemote ConflictMode = "prefer-remote"
+	// ConflictModeNewerWins keeps whichever copy has the more recent mtime.
+	ConflictModeNewerWins ConflictMode = "newer-wins"
+	// ConflictModeRename keeps both copies, renaming the losing side to
+	// <file>.conflict-<host>-<timestamp>, à la Syncthing.
+	ConflictModeRename ConflictMode = "rename"
+	// ConflictModeAbort leaves both copies untouched and surfaces the conflict
+	// for the user to resolve by hand.
+	ConflictModeAbort ConflictMode = "abort"
+)
+
+// conflict describes a file that was modified on both sides since the last
+// successful sync.
+type conflict struct {
+	RelPath string
+}
+
+// detectConflicts asks the current transport which paths currently differ
+// between the local filesystem and the codespace, then, for each one, checks
+// both sides against the mtime+size baseline recorded in s.manifest after
+// the last successful sync to tell "both sides changed independently" (a
+// real conflict) apart from "only one side changed" (not a conflict; the
+// next ordinary sync carries it over cleanly). Delegating the diff to the
+// transport, rather than always shelling out to rsync, means conflict
+// detection works the same way under --transport sftp, where a local rsync
+// binary may not even be installed.
+func (s *syncer) detectConflicts(ctx context.Context) ([]conflict, error) {
+	if len(s.manifest) == 0 {
+		// No baseline at all (e.g. the initial clone, where localDir may not
+		// exist yet), so no path can be a conflict.
+		return nil, nil
+	}
+	transport := s.currentTransport()
+	changed, err := transport.ChangedFiles(ctx, TransportSyncOptions{
+		LocalDir: s.localDir,
+		Src:      s.localDir,
+		Dest:     s.codespaceDir,
+		Excludes: s.excludes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("changed files failed: %w", err)
+	}
+
+	var conflicts []conflict
+	for relPath := range changed {
+		prev, known := s.manifest[relPath]
+		if !known {
+			// No baseline yet; this is the initial copy, not a conflict.
+			continue
+		}
+		localInfo, err := os.Stat(filepath.Join(s.localDir, relPath))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("stat local %s failed: %w", relPath, err)
+		}
+		if !s.manifest.changedSince(relPath, localInfo) {
+			// Local is unchanged since the baseline, so whatever the codespace
+			// side looks like now, this isn't a conflict: the next ordinary
+			// sync carries the codespace's change over cleanly.
+			continue
+		}
+		remoteModTime, err := transport.StatRemote(ctx, s.codespaceDir, relPath)
+		if err != nil {
+			// Can't compare the codespace side (e.g. it no longer exists);
+			// nothing to conflict with.
+			continue
+		}
+		if prev.ModTime.Equal(remoteModTime.Truncate(time.Second)) {
+			// Codespace is unchanged since the baseline; only local changed.
+			continue
+		}
+		conflicts = append(conflicts, conflict{RelPath: relPath})
+	}
+	return conflicts, nil
+}
+
+// resolveConflicts applies s.conflictMode to each detected conflict for the
+// sync pass currently running (pushing reports whether that pass is local ->
+// codespace). It returns the paths that must be excluded from this pass
+// because this pass's direction is the losing side, plus the conflicts that
+// remain unresolved for the user to handle by hand (ConflictModeAbort only).
+func (s *syncer) resolveConflicts(ctx context.Context, conflicts []conflict, pushing bool) (exclude []string, unresolved []conflict, err error) {
+	for _, c := range conflicts {
+		switch s.conflictMode {
+		case ConflictModePreferLocal:
+			// Local wins: only exclude from the pass that would overwrite it,
+			// i.e. codespace -> local.
+			if !pushing {
+				exclude = append(exclude, c.RelPath)
+			}
+		case ConflictModePreferRemote:
+			// Remote wins: only exclude from the pass that would overwrite it,
+			// i.e. local -> codespace.
+			if pushing {
+				exclude = append(exclude, c.RelPath)
+			}
+		case ConflictModeNewerWins:
+			localNewer, err := s.localIsNewer(ctx, c.RelPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("compare mtimes for %s failed: %w", c.RelPath, err)
+			}
+			// Exclude unless this pass is the one carrying the newer copy.
+			if localNewer != pushing {
+				exclude = append(exclude, c.RelPath)
+			}
+		case ConflictModeRename:
+			if err := s.renameLosingSide(ctx, c.RelPath, pushing); err != nil {
+				return nil, nil, fmt.Errorf("rename conflict %s failed: %w", c.RelPath, err)
+			}
+		default: // ConflictModeAbort, or unset
+			exclude = append(exclude, c.RelPath)
+			unresolved = append(unresolved, c)
+		}
+	}
+	return exclude, unresolved, nil
+}
+
+// localIsNewer reports whether relPath's local mtime is more recent than its
+// mtime on the codespace side, for ConflictModeNewerWins.
+func (s *syncer) localIsNewer(ctx context.Context, relPath string) (bool, error) {
+	localInfo, err := os.Stat(filepath.Join(s.localDir, relPath))
+	if err != nil {
+		return false, err
+	}
+	remoteModTime, err := s.currentTransport().StatRemote(ctx, s.codespaceDir, relPath)
+	if err != nil {
+		return false, err
+	}
+	return localInfo.ModTime().After(remoteModTime), nil
+}
+
+// renameLosingSide copies aside the copy of relPath that this sync pass is
+// about to overwrite: the remote copy when pushing (local -> codespace), the
+// local copy otherwise, à la Syncthing's <file>.conflict-<host>-<timestamp>.
+func (s *syncer) renameLosingSide(ctx context.Context, relPath string, pushing bool) error {
+	if !pushing {
+		src := filepath.Join(s.localDir, relPath)
+		b, err := os.ReadFile(src)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		dest := fmt.Sprintf("%s.conflict-local-%d", src, time.Now().UnixNano())
+		return os.WriteFile(dest, b, 0o644)
+	}
+
+	b, err := s.currentTransport().ReadRemoteFile(ctx, s.codespaceDir, relPath)
+	if err != nil {
+		return fmt.Errorf("read remote file failed: %w", err)
+	}
+	dest := filepath.Join(s.localDir, fmt.Sprintf("%s.conflict-remote-%d", relPath, time.Now().UnixNano()))
+	return os.WriteFile(dest, b, 0o644)
+}
+
+// UnresolvedConflicts returns the relative paths of conflicts waiting on
+// manual resolution (ConflictModeAbort).
+func (s *syncer) UnresolvedConflicts() []string {
+	s.conflictsMu.Lock()
+	defer s.conflictsMu.Unlock()
+
+	paths := make([]string, 0, len(s.conflicts))
+	for _, c := range s.conflicts {
+		paths = append(paths, c.RelPath)
+	}
+	return paths
+}
+
+func relPath(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return strings.TrimPrefix(rel, "./")
+}
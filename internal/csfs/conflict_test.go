@@ -0,0 +1,219 @@
+package csfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeTransport is a minimal Transport stub for exercising conflict
+// detection/resolution without shelling out to rsync or ssh.
+type fakeTransport struct {
+	changed      map[string]bool
+	remoteMTimes map[string]time.Time
+	remoteFiles  map[string][]byte
+}
+
+func (f *fakeTransport) Sync(ctx context.Context, opts TransportSyncOptions) error {
+	return nil
+}
+
+func (f *fakeTransport) ChangedFiles(ctx context.Context, opts TransportSyncOptions) (map[string]bool, error) {
+	return f.changed, nil
+}
+
+func (f *fakeTransport) StatRemote(ctx context.Context, codespaceDir, relPath string) (time.Time, error) {
+	t, ok := f.remoteMTimes[relPath]
+	if !ok {
+		return time.Time{}, os.ErrNotExist
+	}
+	return t, nil
+}
+
+func (f *fakeTransport) ReadRemoteFile(ctx context.Context, codespaceDir, relPath string) ([]byte, error) {
+	b, ok := f.remoteFiles[relPath]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return b, nil
+}
+
+// newTestSyncer builds a syncer with a real local directory but a
+// fakeTransport, so detectConflicts can be exercised against controlled
+// local mtimes and a scripted remote side.
+func newTestSyncer(t *testing.T, ft *fakeTransport) *syncer {
+	t.Helper()
+	return &syncer{
+		localDir:     t.TempDir(),
+		codespaceDir: "user@host:/workspaces/foo",
+		manifest:     manifest{},
+		transport:    ft,
+	}
+}
+
+func writeLocalFile(t *testing.T, s *syncer, relPath string, mtime time.Time) os.FileInfo {
+	t.Helper()
+	full := filepath.Join(s.localDir, relPath)
+	if err := os.WriteFile(full, []byte("content"), 0o644); err != nil {
+		t.Fatalf("write local file failed: %v", err)
+	}
+	if err := os.Chtimes(full, mtime, mtime); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		t.Fatalf("stat local file failed: %v", err)
+	}
+	return info
+}
+
+func TestDetectConflicts(t *testing.T) {
+	baseline := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	localChanged := baseline.Add(time.Hour)
+	remoteChanged := baseline.Add(2 * time.Hour)
+
+	t.Run("both sides changed since the baseline is a conflict", func(t *testing.T) {
+		ft := &fakeTransport{
+			changed:      map[string]bool{"a.txt": true},
+			remoteMTimes: map[string]time.Time{"a.txt": remoteChanged},
+		}
+		s := newTestSyncer(t, ft)
+		writeLocalFile(t, s, "a.txt", localChanged)
+		s.manifest["a.txt"] = fileState{ModTime: baseline, Size: 7}
+
+		conflicts, err := s.detectConflicts(context.Background())
+		if err != nil {
+			t.Fatalf("detectConflicts failed: %v", err)
+		}
+		if len(conflicts) != 1 || conflicts[0].RelPath != "a.txt" {
+			t.Fatalf("expected a.txt to be a conflict, got %+v", conflicts)
+		}
+	})
+
+	t.Run("only local changed is not a conflict", func(t *testing.T) {
+		ft := &fakeTransport{
+			changed:      map[string]bool{"a.txt": true},
+			remoteMTimes: map[string]time.Time{"a.txt": baseline},
+		}
+		s := newTestSyncer(t, ft)
+		writeLocalFile(t, s, "a.txt", localChanged)
+		s.manifest["a.txt"] = fileState{ModTime: baseline, Size: 7}
+
+		conflicts, err := s.detectConflicts(context.Background())
+		if err != nil {
+			t.Fatalf("detectConflicts failed: %v", err)
+		}
+		if len(conflicts) != 0 {
+			t.Fatalf("expected no conflicts, got %+v", conflicts)
+		}
+	})
+
+	t.Run("only remote changed is not a conflict", func(t *testing.T) {
+		ft := &fakeTransport{
+			changed:      map[string]bool{"a.txt": true},
+			remoteMTimes: map[string]time.Time{"a.txt": remoteChanged},
+		}
+		s := newTestSyncer(t, ft)
+		info := writeLocalFile(t, s, "a.txt", baseline)
+		s.manifest.record("a.txt", info)
+
+		conflicts, err := s.detectConflicts(context.Background())
+		if err != nil {
+			t.Fatalf("detectConflicts failed: %v", err)
+		}
+		if len(conflicts) != 0 {
+			t.Fatalf("expected no conflicts, got %+v", conflicts)
+		}
+	})
+
+	t.Run("no manifest baseline means initial copy, not a conflict", func(t *testing.T) {
+		ft := &fakeTransport{
+			changed:      map[string]bool{"a.txt": true},
+			remoteMTimes: map[string]time.Time{"a.txt": remoteChanged},
+		}
+		s := newTestSyncer(t, ft)
+		writeLocalFile(t, s, "a.txt", localChanged)
+
+		conflicts, err := s.detectConflicts(context.Background())
+		if err != nil {
+			t.Fatalf("detectConflicts failed: %v", err)
+		}
+		if len(conflicts) != 0 {
+			t.Fatalf("expected no conflicts for a file with no baseline, got %+v", conflicts)
+		}
+	})
+}
+
+func TestResolveConflicts(t *testing.T) {
+	c := conflict{RelPath: "a.txt"}
+
+	tests := []struct {
+		name           string
+		mode           ConflictMode
+		pushing        bool
+		wantExclude    bool
+		wantUnresolved bool
+	}{
+		{name: "prefer-local excludes the pull pass", mode: ConflictModePreferLocal, pushing: false, wantExclude: true},
+		{name: "prefer-local allows the push pass", mode: ConflictModePreferLocal, pushing: true, wantExclude: false},
+		{name: "prefer-remote excludes the push pass", mode: ConflictModePreferRemote, pushing: true, wantExclude: true},
+		{name: "prefer-remote allows the pull pass", mode: ConflictModePreferRemote, pushing: false, wantExclude: false},
+		{name: "abort excludes and reports unresolved", mode: ConflictModeAbort, pushing: true, wantExclude: true, wantUnresolved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSyncer(t, &fakeTransport{})
+			s.conflictMode = tt.mode
+
+			exclude, unresolved, err := s.resolveConflicts(context.Background(), []conflict{c}, tt.pushing)
+			if err != nil {
+				t.Fatalf("resolveConflicts failed: %v", err)
+			}
+			gotExclude := len(exclude) == 1 && exclude[0] == "a.txt"
+			if gotExclude != tt.wantExclude {
+				t.Errorf("exclude = %v, want excluded=%v", exclude, tt.wantExclude)
+			}
+			if (len(unresolved) > 0) != tt.wantUnresolved {
+				t.Errorf("unresolved = %+v, want unresolved=%v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestResolveConflictsNewerWins(t *testing.T) {
+	older := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	t.Run("local newer excludes the pull pass", func(t *testing.T) {
+		ft := &fakeTransport{remoteMTimes: map[string]time.Time{"a.txt": older}}
+		s := newTestSyncer(t, ft)
+		s.conflictMode = ConflictModeNewerWins
+		writeLocalFile(t, s, "a.txt", newer)
+
+		exclude, _, err := s.resolveConflicts(context.Background(), []conflict{{RelPath: "a.txt"}}, false)
+		if err != nil {
+			t.Fatalf("resolveConflicts failed: %v", err)
+		}
+		if len(exclude) != 1 {
+			t.Fatalf("expected the pull pass to be excluded since local is newer, got %+v", exclude)
+		}
+	})
+
+	t.Run("remote newer excludes the push pass", func(t *testing.T) {
+		ft := &fakeTransport{remoteMTimes: map[string]time.Time{"a.txt": newer}}
+		s := newTestSyncer(t, ft)
+		s.conflictMode = ConflictModeNewerWins
+		writeLocalFile(t, s, "a.txt", older)
+
+		exclude, _, err := s.resolveConflicts(context.Background(), []conflict{{RelPath: "a.txt"}}, true)
+		if err != nil {
+			t.Fatalf("resolveConflicts failed: %v", err)
+		}
+		if len(exclude) != 1 {
+			t.Fatalf("expected the push pass to be excluded since remote is newer, got %+v", exclude)
+		}
+	})
+}
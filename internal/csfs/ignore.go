@@ -0,0 +1,184 @@
+package csfs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a single gitignore-style pattern, kept in the order it
+// appeared in its source so that later rules (including negations) can
+// override earlier ones for the same path, as gitignore itself does.
+type ignoreRule struct {
+	pattern string // pattern with leading/trailing slashes stripped
+	negate  bool   // pattern began with "!"
+
+	anchored bool // pattern began with "/": only matches from the root
+	dirOnly  bool // pattern ended with "/": only matches directories
+
+	re *regexp.Regexp
+}
+
+// ignoreMatcher decides whether a path relative to baseDir should be
+// excluded from watching and syncing. It understands gitignore syntax
+// (**, *, ?, leading "/" anchors, trailing "/" for directories, and "!"
+// negation) and is seeded from explicit patterns plus any .gitignore and
+// .csfsignore found at the root of baseDir.
+type ignoreMatcher struct {
+	baseDir string
+	rules   []ignoreRule
+}
+
+func newIgnoreMatcher(baseDir string, patterns []string) *ignoreMatcher {
+	var all []string
+	all = append(all, patterns...)
+	all = append(all, readIgnoreFile(filepath.Join(baseDir, ".gitignore"))...)
+	all = append(all, readIgnoreFile(filepath.Join(baseDir, ".csfsignore"))...)
+	return newPatternMatcher(baseDir, all)
+}
+
+// newPatternMatcher builds a matcher from exactly the given patterns, unlike
+// newIgnoreMatcher it does not also load .gitignore/.csfsignore. Used for the
+// --watch allow-list, which has no relationship to the exclude rules.
+func newPatternMatcher(baseDir string, patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{baseDir: baseDir}
+	for _, p := range patterns {
+		if r, ok := parseIgnoreRule(p); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+	return m
+}
+
+func readIgnoreFile(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func parseIgnoreRule(pattern string) (ignoreRule, bool) {
+	r := ignoreRule{pattern: pattern}
+	if strings.HasPrefix(r.pattern, "!") {
+		r.negate = true
+		r.pattern = r.pattern[1:]
+	}
+	if strings.HasPrefix(r.pattern, "/") {
+		r.anchored = true
+		r.pattern = strings.TrimPrefix(r.pattern, "/")
+	}
+	if strings.HasSuffix(r.pattern, "/") {
+		r.dirOnly = true
+		r.pattern = strings.TrimSuffix(r.pattern, "/")
+	}
+	if r.pattern == "" {
+		return ignoreRule{}, false
+	}
+	r.re = globToRegexp(r.pattern)
+	return r, true
+}
+
+// globToRegexp translates a gitignore-style glob into an anchored regular
+// expression: "**" crosses directory boundaries, "*" and "?" stay within a
+// single path segment.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '.', '+', '(', ')', '|', '^', '$', '{', '}', '[', ']', '\\':
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		default:
+			b.WriteString(string(c))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Match reports whether relPath (slash-separated, relative to baseDir)
+// should be ignored. isDir lets directory-only patterns take effect.
+func (m *ignoreMatcher) Match(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.matches(relPath) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(relPath string) bool {
+	if r.anchored {
+		return r.re.MatchString(relPath)
+	}
+	if r.re.MatchString(relPath) {
+		return true
+	}
+	// Unanchored patterns match at any depth, e.g. "*.log" also matches "a/b/c.log".
+	segments := strings.Split(relPath, "/")
+	for i := range segments {
+		if r.re.MatchString(strings.Join(segments[i:], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// RsyncArgs translates the rule set into rsync --include/--exclude filter
+// arguments. rsync applies the first rule that matches a path, while
+// gitignore (and Match) let the last one win, so the rules are emitted in
+// reverse order; that way a later negation still re-includes something an
+// earlier, broader exclude matched.
+func (m *ignoreMatcher) RsyncArgs() []string {
+	var args []string
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := m.rules[i]
+		pattern := r.pattern
+		if r.anchored {
+			pattern = "/" + pattern
+		}
+		if r.dirOnly {
+			pattern += "/"
+		}
+		if r.negate {
+			args = append(args, "--include", pattern)
+		} else {
+			args = append(args, "--exclude", pattern)
+		}
+	}
+	return args
+}
@@ -0,0 +1,104 @@
+package csfs
+
+import "testing"
+
+func TestIgnoreMatcherMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{
+			name:     "simple glob matches at any depth",
+			patterns: []string{"*.log"},
+			path:     "a/b/c.log",
+			want:     true,
+		},
+		{
+			name:     "simple glob does not match unrelated file",
+			patterns: []string{"*.log"},
+			path:     "a/b/c.txt",
+			want:     false,
+		},
+		{
+			name:     "anchored pattern only matches from the root",
+			patterns: []string{"/build"},
+			path:     "a/build",
+			want:     false,
+		},
+		{
+			name:     "anchored pattern matches at the root",
+			patterns: []string{"/build"},
+			path:     "build",
+			want:     true,
+		},
+		{
+			name:     "dir-only pattern ignored for files",
+			patterns: []string{"node_modules/"},
+			path:     "node_modules",
+			isDir:    false,
+			want:     false,
+		},
+		{
+			name:     "dir-only pattern matches directories",
+			patterns: []string{"node_modules/"},
+			path:     "node_modules",
+			isDir:    true,
+			want:     true,
+		},
+		{
+			name:     "double-star crosses directory boundaries",
+			patterns: []string{"**/vendor/**"},
+			path:     "a/b/vendor/c/d.go",
+			want:     true,
+		},
+		{
+			name:     "later negation re-includes a file excluded earlier",
+			patterns: []string{"*.log", "!important.log"},
+			path:     "important.log",
+			want:     false,
+		},
+		{
+			name:     "negation only overrides patterns appearing before it",
+			patterns: []string{"!important.log", "*.log"},
+			path:     "important.log",
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPatternMatcher("/base", tt.patterns)
+			if got := m.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%q, isDir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{name: "star stays within a segment", pattern: "*.go", path: "main.go", want: true},
+		{name: "star does not cross a slash", pattern: "*.go", path: "a/main.go", want: false},
+		{name: "question mark matches a single char", pattern: "a?c", path: "abc", want: true},
+		{name: "question mark does not match a slash", pattern: "a?c", path: "a/c", want: false},
+		{name: "double star alone matches everything", pattern: "**", path: "a/b/c", want: true},
+		{name: "literal dot is escaped, not a wildcard", pattern: "a.b", path: "aXb", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := globToRegexp(tt.pattern)
+			if got := re.MatchString(tt.path); got != tt.want {
+				t.Errorf("globToRegexp(%q).MatchString(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package csfs
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Category identifies the csfs subsystem a log line comes from, so
+// CSFS_TRACE can turn on debug output for just the part someone is
+// debugging, mirroring the STTRACE env var Syncthing uses for the same
+// purpose.
+type Category string
+
+const (
+	CategoryWatcher Category = "watcher"
+	CategorySyncer  Category = "syncer"
+	CategorySSH     Category = "ssh"
+	CategoryUI      Category = "ui"
+)
+
+// Logger wraps slog with per-category debug gating: Debug calls are only
+// emitted for categories named in CSFS_TRACE (or all of them, for "all").
+// Info/Warn/Error are always emitted, at whatever the underlying handler's
+// level allows.
+type Logger struct {
+	base  *slog.Logger
+	trace map[Category]bool
+}
+
+// NewLogger builds a Logger writing to w, as text by default or JSON when
+// jsonOutput is set.
+func NewLogger(w io.Writer, jsonOutput bool) *Logger {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	if jsonOutput {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return &Logger{base: slog.New(handler), trace: parseTrace(os.Getenv("CSFS_TRACE"))}
+}
+
+func parseTrace(v string) map[Category]bool {
+	trace := make(map[Category]bool)
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			trace[Category(part)] = true
+		}
+	}
+	return trace
+}
+
+func (l *Logger) enabled(c Category) bool {
+	return l.trace["all"] || l.trace[c]
+}
+
+func (l *Logger) Debug(c Category, msg string, args ...any) {
+	if l.enabled(c) {
+		l.base.Debug(msg, append([]any{"category", string(c)}, args...)...)
+	}
+}
+
+func (l *Logger) Info(c Category, msg string, args ...any) {
+	l.base.Info(msg, append([]any{"category", string(c)}, args...)...)
+}
+
+func (l *Logger) Warn(c Category, msg string, args ...any) {
+	l.base.Warn(msg, append([]any{"category", string(c)}, args...)...)
+}
+
+func (l *Logger) Error(c Category, msg string, args ...any) {
+	l.base.Error(msg, append([]any{"category", string(c)}, args...)...)
+}
+
+// log is the package-wide Logger every subsystem writes through. It
+// discards output until App.Run calls SetLogger once --log-file/--log-json
+// have been parsed, so debugging a stuck sync no longer requires a code
+// edit, and by default nothing competes with the spinner-driven TTY output.
+var log = NewLogger(io.Discard, false)
+
+// SetLogger replaces the package-wide Logger.
+func SetLogger(l *Logger) {
+	log = l
+}
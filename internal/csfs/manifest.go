@@ -0,0 +1,71 @@
+package csfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileState records the last known state of a synced file so that a later
+// sync can tell whether it changed independently on both sides.
+type fileState struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+}
+
+// manifest is a per-file mtime+size snapshot taken after each successful
+// sync, stored under <localDir>/.csfs/state.json. It lets the syncer tell
+// "changed since the last sync" apart from "always differs" when detecting
+// conflicts.
+type manifest map[string]fileState
+
+func manifestPath(localDir string) string {
+	return filepath.Join(localDir, ".csfs", "state.json")
+}
+
+func loadManifest(localDir string) (manifest, error) {
+	b, err := os.ReadFile(manifestPath(localDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest{}, nil
+		}
+		return nil, fmt.Errorf("read manifest failed: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal manifest failed: %w", err)
+	}
+	return m, nil
+}
+
+func (m manifest) save(localDir string) error {
+	path := manifestPath(localDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("mkdir manifest dir failed: %w", err)
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest failed: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("write manifest failed: %w", err)
+	}
+	return nil
+}
+
+// changedSince reports whether path's current state differs from the
+// manifest's recorded state. An unseen path is considered changed, since
+// there is nothing to compare it against.
+func (m manifest) changedSince(relPath string, fi os.FileInfo) bool {
+	prev, ok := m[relPath]
+	if !ok {
+		return true
+	}
+	return !prev.ModTime.Equal(fi.ModTime().Truncate(time.Second)) || prev.Size != fi.Size()
+}
+
+func (m manifest) record(relPath string, fi os.FileInfo) {
+	m[relPath] = fileState{ModTime: fi.ModTime().Truncate(time.Second), Size: fi.Size()}
+}
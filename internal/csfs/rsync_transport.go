@@ -0,0 +1,141 @@
+package csfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rsyncTransport shells out to the system rsync binary over the SSH tunnel
+// opened by sshServer. It's the original csfs transport; sftpTransport
+// exists as an alternative for hosts without a local rsync binary.
+type rsyncTransport struct {
+	port int64
+}
+
+func newRsyncTransport(port int64) *rsyncTransport {
+	return &rsyncTransport{port: port}
+}
+
+func (t *rsyncTransport) Sync(ctx context.Context, opts TransportSyncOptions) error {
+	args := []string{
+		"--archive",
+		"--compress",
+		"--update",
+		"--perms",
+		"--hard-links",
+		"-e",
+		t.sshCommand(),
+	}
+	if opts.DeleteFiles {
+		args = append(args, "--delete")
+	}
+	args = append(args, newIgnoreMatcher(opts.LocalDir, opts.Excludes).RsyncArgs()...)
+	if len(opts.Files) > 0 {
+		args = append(args, "--files-from=-", "--relative")
+	}
+	args = append(args, srcDirWithSuffix(opts.Src), opts.Dest)
+
+	cmd := exec.CommandContext(ctx, "rsync", args...)
+	if len(opts.Files) > 0 {
+		cmd.Stdin = strings.NewReader(strings.Join(opts.Files, "\n") + "\n")
+	}
+	return cmd.Run()
+}
+
+// ChangedFiles runs a dry-run rsync from Src to Dest and returns the set of
+// relative paths that would be transferred. Unlike Sync, this intentionally
+// omits --update: detectConflicts compares each candidate path against the
+// stored manifest baseline on both sides to decide whether it's a real
+// conflict, and --update would make rsync skip (not itemize) whichever side
+// is older, silently hiding candidates instead of just narrowing them.
+func (t *rsyncTransport) ChangedFiles(ctx context.Context, opts TransportSyncOptions) (map[string]bool, error) {
+	args := []string{
+		"--dry-run",
+		"--itemize-changes",
+		"--archive",
+		"-e",
+		t.sshCommand(),
+	}
+	args = append(args, newIgnoreMatcher(opts.LocalDir, opts.Excludes).RsyncArgs()...)
+	args = append(args, srcDirWithSuffix(opts.Src), opts.Dest)
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "rsync", args...)
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return parseItemizedPaths(&out), nil
+}
+
+// StatRemote shells out to a plain `ssh ... stat` (not rsync, which has no
+// single-file stat mode) to read relPath's mtime on the codespace side.
+func (t *rsyncTransport) StatRemote(ctx context.Context, codespaceDir, relPath string) (time.Time, error) {
+	userHost, remoteRoot := splitCodespaceDir(codespaceDir)
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", "-p", strconv.FormatInt(t.port, 10),
+		"-o", "NoHostAuthenticationForLocalhost=yes", "-o", "PasswordAuthentication=no",
+		userHost, "stat", "-c", "%Y", path.Join(remoteRoot, relPath))
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return time.Time{}, err
+	}
+	epoch, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse remote mtime failed: %w", err)
+	}
+	return time.Unix(epoch, 0), nil
+}
+
+// ReadRemoteFile shells out to `ssh ... cat` to read relPath's contents on
+// the codespace side.
+func (t *rsyncTransport) ReadRemoteFile(ctx context.Context, codespaceDir, relPath string) ([]byte, error) {
+	userHost, remoteRoot := splitCodespaceDir(codespaceDir)
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", "-p", strconv.FormatInt(t.port, 10),
+		"-o", "NoHostAuthenticationForLocalhost=yes", "-o", "PasswordAuthentication=no",
+		userHost, "cat", path.Join(remoteRoot, relPath))
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (t *rsyncTransport) sshCommand() string {
+	return fmt.Sprintf("ssh -p %d -o NoHostAuthenticationForLocalhost=yes -o PasswordAuthentication=no", t.port)
+}
+
+// parseItemizedPaths extracts the relative paths of entries rsync marked as
+// needing a content transfer from --itemize-changes output.
+func parseItemizedPaths(r *bytes.Buffer) map[string]bool {
+	changed := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 12 {
+			continue
+		}
+		code, rest := line[:11], line[12:]
+		// Only care about regular files with an actual content update (the
+		// checksum or size position in the itemize code is not '.').
+		if code[0] != '>' && code[0] != '<' {
+			continue
+		}
+		if code[1] != 'f' {
+			continue
+		}
+		if code[2] == '.' && code[3] == '.' {
+			continue
+		}
+		changed[rest] = true
+	}
+	return changed
+}
@@ -0,0 +1,419 @@
+package csfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sftpTransport is a pure-Go alternative to rsyncTransport: it dials the
+// local port opened by sshServer directly and does its own stat-diff and
+// parallel upload/download instead of shelling out to rsync. This removes
+// the hard dependency on a locally-installed rsync binary, a blocker on
+// Windows and on minimal container hosts.
+type sftpTransport struct {
+	port     int64
+	username string
+	workers  int
+}
+
+func newSFTPTransport(port int64, username []byte) *sftpTransport {
+	return &sftpTransport{port: port, username: string(username), workers: 8}
+}
+
+func (t *sftpTransport) Sync(ctx context.Context, opts TransportSyncOptions) error {
+	client, err := t.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("dial failed: %w", err)
+	}
+	defer client.Close()
+
+	pushing := opts.Src == opts.LocalDir
+	localRoot, remoteRoot := opts.Src, remoteRootPath(opts.Dest)
+	if !pushing {
+		localRoot, remoteRoot = opts.Dest, remoteRootPath(opts.Src)
+	}
+
+	excluded := newIgnoreMatcher(opts.LocalDir, opts.Excludes)
+	files := opts.Files
+	if len(files) == 0 {
+		files, err = t.dirtyFiles(client, pushing, localRoot, remoteRoot, excluded)
+		if err != nil {
+			return fmt.Errorf("diff failed: %w", err)
+		}
+	} else {
+		// Files named by the caller (e.g. the watcher's dirty set) may use the
+		// OS path separator; every path below this point is compared against
+		// and sent to a Unix sftp server, so normalize up front.
+		norm := make([]string, len(files))
+		for i, f := range files {
+			norm[i] = filepath.ToSlash(f)
+		}
+		files = norm
+	}
+
+	if err := t.transfer(ctx, client, pushing, localRoot, remoteRoot, files); err != nil {
+		return err
+	}
+
+	if opts.DeleteFiles {
+		return t.deleteStale(client, pushing, localRoot, remoteRoot, excluded)
+	}
+	return nil
+}
+
+// ChangedFiles reports the relative paths that differ between Src and Dest,
+// reusing the same stat-diff dirtyFiles performs ahead of an actual sync.
+func (t *sftpTransport) ChangedFiles(ctx context.Context, opts TransportSyncOptions) (map[string]bool, error) {
+	client, err := t.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dial failed: %w", err)
+	}
+	defer client.Close()
+
+	pushing := opts.Src == opts.LocalDir
+	localRoot, remoteRoot := opts.Src, remoteRootPath(opts.Dest)
+	if !pushing {
+		localRoot, remoteRoot = opts.Dest, remoteRootPath(opts.Src)
+	}
+
+	rel, err := t.dirtyFiles(client, pushing, localRoot, remoteRoot, newIgnoreMatcher(opts.LocalDir, opts.Excludes))
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool, len(rel))
+	for _, r := range rel {
+		changed[r] = true
+	}
+	return changed, nil
+}
+
+// StatRemote returns relPath's modification time on the codespace side.
+func (t *sftpTransport) StatRemote(ctx context.Context, codespaceDir, relPath string) (time.Time, error) {
+	client, err := t.dial(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("dial failed: %w", err)
+	}
+	defer client.Close()
+
+	info, err := client.Stat(path.Join(remoteRootPath(codespaceDir), relPath))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// ReadRemoteFile returns relPath's contents on the codespace side.
+func (t *sftpTransport) ReadRemoteFile(ctx context.Context, codespaceDir, relPath string) ([]byte, error) {
+	client, err := t.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dial failed: %w", err)
+	}
+	defer client.Close()
+
+	f, err := client.Open(path.Join(remoteRootPath(codespaceDir), relPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (t *sftpTransport) dial(ctx context.Context) (*sftp.Client, error) {
+	signers, err := sshAgentSigners()
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User: t.username,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		// The tunnel is a freshly-opened localhost port from `gh cs ssh --server-port=0`;
+		// there's no host key to pin, matching the rsync transport's
+		// NoHostAuthenticationForLocalhost=yes.
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         10 * time.Second,
+	}
+	addr := fmt.Sprintf("localhost:%d", t.port)
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial tcp failed: %w", err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("ssh handshake failed: %w", err)
+	}
+	return sftp.NewClient(ssh.NewClient(sshConn, chans, reqs))
+}
+
+func sshAgentSigners() ([]ssh.Signer, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("SSH_AUTH_SOCK not set; the sftp transport needs a running ssh-agent")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("dial ssh agent failed: %w", err)
+	}
+	return agent.NewClient(conn).Signers()
+}
+
+func remoteRootPath(codespaceDir string) string {
+	if i := strings.Index(codespaceDir, ":"); i != -1 {
+		return codespaceDir[i+1:]
+	}
+	return codespaceDir
+}
+
+// fileEntry is a relative path and the size/mtime recorded for it by
+// whichever side (local or remote) it was listed from.
+type fileEntry struct {
+	rel     string
+	size    int64
+	modTime time.Time
+}
+
+// listLocal walks root on the local filesystem and returns its regular
+// files, skipping anything excluded. rel is always slash-separated.
+func (t *sftpTransport) listLocal(root string, excluded *ignoreMatcher) ([]fileEntry, error) {
+	var entries []fileEntry
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(relPath(root, p))
+		if rel == "." {
+			return nil
+		}
+		if excluded.Match(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		entries = append(entries, fileEntry{rel: rel, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return entries, err
+}
+
+// listRemote walks root on the codespace side over the sftp connection and
+// returns its regular files, skipping anything excluded.
+func (t *sftpTransport) listRemote(client *sftp.Client, root string, excluded *ignoreMatcher) ([]fileEntry, error) {
+	var entries []fileEntry
+	walker := client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		info := walker.Stat()
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+		if rel == "" {
+			continue
+		}
+		if excluded.Match(rel, info.IsDir()) {
+			if info.IsDir() {
+				walker.SkipDir()
+			}
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+		entries = append(entries, fileEntry{rel: rel, size: info.Size(), modTime: info.ModTime()})
+	}
+	return entries, nil
+}
+
+// dirtyFiles walks the source side of the sync (the remote tree when
+// pulling, so files that exist only on the codespace are found too) and
+// returns the paths (relative to localRoot/remoteRoot) whose size or mtime
+// differs on the destination side, i.e. everything a full sync would need to
+// transfer.
+func (t *sftpTransport) dirtyFiles(client *sftp.Client, pushing bool, localRoot, remoteRoot string, excluded *ignoreMatcher) ([]string, error) {
+	var src []fileEntry
+	var err error
+	if pushing {
+		src, err = t.listLocal(localRoot, excluded)
+	} else {
+		src, err = t.listRemote(client, remoteRoot, excluded)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dirty []string
+	for _, e := range src {
+		var destSize int64
+		var destModTime time.Time
+		var statErr error
+		if pushing {
+			var info os.FileInfo
+			info, statErr = client.Lstat(path.Join(remoteRoot, e.rel))
+			if statErr == nil {
+				destSize, destModTime = info.Size(), info.ModTime()
+			}
+		} else {
+			var info os.FileInfo
+			info, statErr = os.Lstat(filepath.Join(localRoot, filepath.FromSlash(e.rel)))
+			if statErr == nil {
+				destSize, destModTime = info.Size(), info.ModTime()
+			}
+		}
+		if statErr != nil || destSize != e.size || !destModTime.Equal(e.modTime) {
+			dirty = append(dirty, e.rel)
+		}
+	}
+	return dirty, nil
+}
+
+// deleteStale removes destination-side files that no longer exist on the
+// source side, mirroring rsyncTransport's --delete.
+func (t *sftpTransport) deleteStale(client *sftp.Client, pushing bool, localRoot, remoteRoot string, excluded *ignoreMatcher) error {
+	var src, dest []fileEntry
+	var err error
+	if pushing {
+		src, err = t.listLocal(localRoot, excluded)
+	} else {
+		src, err = t.listRemote(client, remoteRoot, excluded)
+	}
+	if err != nil {
+		return fmt.Errorf("list source failed: %w", err)
+	}
+	if pushing {
+		dest, err = t.listRemote(client, remoteRoot, excluded)
+	} else {
+		dest, err = t.listLocal(localRoot, excluded)
+	}
+	if err != nil {
+		return fmt.Errorf("list dest failed: %w", err)
+	}
+
+	have := make(map[string]struct{}, len(src))
+	for _, e := range src {
+		have[e.rel] = struct{}{}
+	}
+	for _, e := range dest {
+		if _, ok := have[e.rel]; ok {
+			continue
+		}
+		if pushing {
+			if err := client.Remove(path.Join(remoteRoot, e.rel)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove remote %s failed: %w", e.rel, err)
+			}
+		} else {
+			if err := os.Remove(filepath.Join(localRoot, filepath.FromSlash(e.rel))); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove local %s failed: %w", e.rel, err)
+			}
+		}
+	}
+	return nil
+}
+
+// transfer copies files (relative to localRoot/remoteRoot) in parallel,
+// in the direction indicated by pushing, using a small worker pool.
+func (t *sftpTransport) transfer(ctx context.Context, client *sftp.Client, pushing bool, localRoot, remoteRoot string, files []string) error {
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	errs := make(chan error, t.workers)
+
+	for i := 0; i < t.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range paths {
+				var err error
+				if pushing {
+					err = t.push(client, localRoot, remoteRoot, rel)
+				} else {
+					err = t.pull(client, localRoot, remoteRoot, rel)
+				}
+				if err != nil {
+					select {
+					case errs <- fmt.Errorf("%s: %w", rel, err):
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+sendLoop:
+	for _, rel := range files {
+		select {
+		case <-ctx.Done():
+			break sendLoop
+		case paths <- rel:
+		}
+	}
+	close(paths)
+	wg.Wait()
+	close(errs)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	for err := range errs {
+		return err
+	}
+	return nil
+}
+
+func (t *sftpTransport) push(client *sftp.Client, localRoot, remoteRoot, rel string) error {
+	src, err := os.Open(filepath.Join(localRoot, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	remotePath := path.Join(remoteRoot, rel)
+	if err := client.MkdirAll(path.Dir(remotePath)); err != nil {
+		return err
+	}
+	dest, err := client.Create(remotePath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	_, err = io.Copy(dest, src)
+	return err
+}
+
+func (t *sftpTransport) pull(client *sftp.Client, localRoot, remoteRoot, rel string) error {
+	src, err := client.Open(path.Join(remoteRoot, rel))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	localPath := filepath.Join(localRoot, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return err
+	}
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	_, err = io.Copy(dest, src)
+	return err
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -18,48 +20,134 @@ type sshServerConn struct {
 type sshServer struct {
 	codespace string
 
+	keepaliveInterval time.Duration
+
+	mu        sync.Mutex
 	ghProcess *exec.Cmd
-	ready     chan sshServerConn
+
+	ready chan sshServerConn
+
+	// logFile, when set, is where ghProcess's stdout/stderr are redirected
+	// instead of the in-process writer. See newDetachedSSHServer.
+	logFile *os.File
 }
 
 func newSSHServer(codespace string) *sshServer {
 	return &sshServer{
-		codespace: codespace,
-		ready:     make(chan sshServerConn),
+		codespace:         codespace,
+		keepaliveInterval: 10 * time.Second,
+		ready:             make(chan sshServerConn),
 	}
 }
 
+// newDetachedSSHServer is like newSSHServer, but for a tunnel that must keep
+// running after this process exits (e.g. ConfigSSH's persistent entry). It
+// redirects ghProcess's stdout/stderr straight to logFile instead of the
+// pipe exec.Cmd sets up for a non-*os.File Stdout/Stderr: that pipe is read
+// by a goroutine in *this* process, so its read end disappears the moment
+// this process exits, and the child then gets EPIPE/SIGPIPE on its next
+// write -- liable to kill exactly the tunnel the caller wanted left running.
+// A real file's descriptor has no such dependency on this process staying
+// alive.
+func newDetachedSSHServer(codespace string, logFile *os.File) *sshServer {
+	s := newSSHServer(codespace)
+	s.logFile = logFile
+	return s
+}
+
 func (s *sshServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.ghProcess != nil {
 		return s.ghProcess.Cancel()
 	}
 	return nil
 }
 
+// Listen starts the SSH tunnel and supervises it for the lifetime of ctx: if
+// the `gh cs ssh` process exits unexpectedly, or the keepalive probe finds
+// the tunnel has gone half-open (e.g. the laptop slept or switched
+// networks), it tears the tunnel down and reconnects with exponential
+// backoff, instead of letting the whole app die on the first hiccup. Each
+// successful (re)connect is sent on Ready().
 func (s *sshServer) Listen(ctx context.Context) error {
-	errch := make(chan error, 3)       // writer + process + ensureReady
-	wch := make(chan sshServerConn, 1) // writer
-	w := newWriter(errch, wch)
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		err := s.connectOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+
+		log.Error(CategorySSH, "tunnel lost, reconnecting", "error", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// connectOnce starts a single `gh cs ssh` process, waits for it to report
+// connection details, and then supervises it (via a keepalive probe) until
+// either it exits, the probe fails, or ctx is done.
+func (s *sshServer) connectOnce(ctx context.Context) error {
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errch := make(chan error, 3)       // writer/tail + process + ensureReady/keepalive
+	wch := make(chan sshServerConn, 1) // writer/tail
+
 	args := []string{"cs", "ssh", "-c", s.codespace, "--server-port=0", "--", "-tt"}
-	s.ghProcess = exec.CommandContext(ctx, "gh", args...)
-	s.ghProcess.Stderr = w
-	s.ghProcess.Stdout = w
+	ghProcess := exec.CommandContext(connCtx, "gh", args...)
+
+	if s.logFile != nil {
+		// A detached server must keep running after this process exits, so
+		// ghProcess's stdout/stderr go straight to the log file's descriptor
+		// rather than through a *writer: a non-*os.File Stdout/Stderr makes
+		// exec.Cmd set up a pipe read by a goroutine in this process, and
+		// that goroutine (and the pipe's read end) dies the moment this
+		// process does, handing ghProcess EPIPE/SIGPIPE on its next write.
+		ghProcess.Stdout = s.logFile
+		ghProcess.Stderr = s.logFile
+		go tailConnectionDetails(connCtx, s.logFile.Name(), wch, errch)
+	} else {
+		w := newWriter(errch, wch)
+		ghProcess.Stderr = w
+		ghProcess.Stdout = w
+	}
+
+	s.mu.Lock()
+	s.ghProcess = ghProcess
+	s.mu.Unlock()
+
 	go func() {
 		select {
-		case <-ctx.Done():
+		case <-connCtx.Done():
 			return
 		case conn := <-wch:
 			// writer has received the connection details, test the port is listening
-			if err := s.ensureReady(ctx, conn); err != nil {
+			if err := s.ensureReady(connCtx, conn); err != nil {
 				errch <- fmt.Errorf("failed to ensure port is ready: %w", err)
 				return
 			}
 			s.ready <- conn
+			go s.keepalive(connCtx, conn, errch)
 		}
 	}()
 	go func() {
-		errch <- s.ghProcess.Run()
+		errch <- ghProcess.Run()
 	}()
+
 	select {
 	case <-ctx.Done():
 		return nil
@@ -87,6 +175,30 @@ func (s *sshServer) ensureReady(ctx context.Context, c sshServerConn) error {
 	return nil
 }
 
+// keepalive periodically dials conn.Port so a half-open tunnel -- common
+// when a laptop sleeps or switches networks -- is detected and rebuilt,
+// instead of silently hanging until the next sync attempt times out. The
+// reconnect is reported only through the CategorySSH log; nothing is
+// printed to the terminal.
+func (s *sshServer) keepalive(ctx context.Context, conn sshServerConn, errch chan error) {
+	ticker := time.NewTicker(s.keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			probe, err := net.DialTimeout("tcp", fmt.Sprintf(":%d", conn.Port), 2*time.Second)
+			if err != nil {
+				errch <- fmt.Errorf("keepalive probe failed: %w", err)
+				return
+			}
+			probe.Close()
+		}
+	}
+}
+
 func (s *sshServer) Ready() <-chan sshServerConn {
 	return s.ready
 }
@@ -105,31 +217,78 @@ func newWriter(errch chan error, ready chan sshServerConn) *writer {
 
 func (w *writer) Write(p []byte) (n int, err error) {
 	if bytes.HasPrefix(p, []byte("Connection Details")) {
-		p := bytes.Split(p, []byte(" "))
-		// Format is: Connection Details: ssh codespace@localhost [-p 1234 ...]
-		// There should be at least 6 parts
-		if len(p) < 6 {
-			w.errch <- fmt.Errorf("invalid connection details: %s", p)
+		conn, perr := parseConnectionDetailsLine(p)
+		if perr != nil {
+			w.errch <- perr
 			return len(p), nil
 		}
-		// The username is in the 4th part
-		uhost := bytes.Split(p[3], []byte("@"))
-		if len(uhost) != 2 {
-			w.errch <- fmt.Errorf("invalid connection details for username: %s", p)
-			return len(p), nil
-		}
-		username := uhost[0]
-		// The port is in the 6th part
-		port, err := strconv.ParseInt(string(p[5]), 10, 0)
-		if err != nil {
-			w.errch <- fmt.Errorf("invalid connection details for port: %s", p)
-			return len(p), nil
-		}
-		w.ready <- sshServerConn{
-			Username: username,
-			Port:     port,
-		}
+		log.Debug(CategorySSH, "connection details parsed", "username", string(conn.Username), "port", conn.Port)
+		w.ready <- conn
 		close(w.ready)
 	}
 	return len(p), nil
 }
+
+// parseConnectionDetailsLine parses a `gh cs ssh` "Connection Details: ssh
+// codespace@localhost -p 1234 ..." line, as printed to either the writer (the
+// foreground path) or the tunnel's log file (the detached path).
+func parseConnectionDetailsLine(p []byte) (sshServerConn, error) {
+	parts := bytes.Split(p, []byte(" "))
+	// There should be at least 6 parts.
+	if len(parts) < 6 {
+		return sshServerConn{}, fmt.Errorf("invalid connection details: %s", p)
+	}
+	// The username is in the 4th part.
+	uhost := bytes.Split(parts[3], []byte("@"))
+	if len(uhost) != 2 {
+		return sshServerConn{}, fmt.Errorf("invalid connection details for username: %s", p)
+	}
+	username := uhost[0]
+	// The port is in the 6th part.
+	port, err := strconv.ParseInt(string(parts[5]), 10, 0)
+	if err != nil {
+		return sshServerConn{}, fmt.Errorf("invalid connection details for port: %s", p)
+	}
+	return sshServerConn{Username: username, Port: port}, nil
+}
+
+// tailConnectionDetails polls logPath for the "Connection Details" line
+// ghProcess writes to it once the tunnel is up, since the detached path
+// redirects ghProcess's stdout/stderr to the log file directly rather than
+// through a *writer it could call back into.
+func tailConnectionDetails(ctx context.Context, logPath string, ready chan<- sshServerConn, errch chan<- error) {
+	// The log file is opened for append, so it may already hold an earlier
+	// tunnel's details; only look at what is written after this point.
+	var offset int
+	if info, err := os.Stat(logPath); err == nil {
+		offset = int(info.Size())
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b, err := os.ReadFile(logPath)
+			if err != nil || len(b) < offset {
+				continue
+			}
+			for _, line := range bytes.Split(b[offset:], []byte("\n")) {
+				if !bytes.HasPrefix(line, []byte("Connection Details")) {
+					continue
+				}
+				conn, err := parseConnectionDetailsLine(line)
+				if err != nil {
+					errch <- err
+					return
+				}
+				log.Debug(CategorySSH, "connection details parsed from log", "username", string(conn.Username), "port", conn.Port)
+				ready <- conn
+				return
+			}
+		}
+	}
+}
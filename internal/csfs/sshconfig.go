@@ -0,0 +1,208 @@
+package csfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sshConfigBeginPrefix = "# BEGIN csfs "
+	sshConfigEndPrefix   = "# END csfs "
+)
+
+// ConfigSSH writes (or removes) a managed Host block in the user's ~/.ssh/config
+// for the given codespace, so that standard SSH tooling (VSCode Remote-SSH,
+// rsync, scp, sshfs) can connect to it directly without going through
+// `gh cs ssh`.
+func (a *App) ConfigSSH(ctx context.Context, codespaceName string, remove bool) (err error) {
+	codespace, err := a.getOrChooseCodespace(ctx, codespaceName)
+	if err != nil {
+		if errors.Is(err, errInterrupt) {
+			return nil
+		}
+		return fmt.Errorf("get or choose codespace failed: %w", err)
+	}
+
+	configPath, err := sshConfigPath()
+	if err != nil {
+		return fmt.Errorf("ssh config path failed: %w", err)
+	}
+
+	if remove {
+		if err := removeSSHConfigBlock(configPath, codespace.Name); err != nil {
+			return fmt.Errorf("remove ssh config block failed: %w", err)
+		}
+		fmt.Printf("Removed csfs entry for %s from %s\n", codespace.Name, configPath)
+		return nil
+	}
+
+	// Unlike Run, the tunnel started here must outlive this command: the
+	// whole point of config-ssh is that `ssh <codespace>` keeps working after
+	// csfs exits. So the server is only closed if something below fails
+	// before the config is written; once the entry is in place, ghProcess is
+	// left running in the background for standard SSH tooling to use.
+	logPath, err := sshTunnelLogPath(codespace.Name)
+	if err != nil {
+		return fmt.Errorf("ssh tunnel log path failed: %w", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open ssh tunnel log failed: %w", err)
+	}
+	defer logFile.Close()
+
+	errch := make(chan error, 2) // server, ready
+	server := newDetachedSSHServer(codespace.Name, logFile)
+	go func() {
+		if err := server.Listen(ctx); err != nil {
+			errch <- fmt.Errorf("ssh server failed: %w", err)
+		}
+	}()
+
+	var conn sshServerConn
+	err = a.op("Connecting to codespace", func() error {
+		conn, err = a.waitForSSHServer(ctx, errch, server)
+		return err
+	})
+	if err != nil {
+		if closeErr := server.Close(); closeErr != nil {
+			err = fmt.Errorf("%w (ssh server close failed: %v)", err, closeErr)
+		}
+		return fmt.Errorf("ssh server ready failed: %w", err)
+	}
+
+	block := sshConfigBlock(codespace.Name, conn)
+	if err := upsertSSHConfigBlock(configPath, codespace.Name, block); err != nil {
+		if closeErr := server.Close(); closeErr != nil {
+			err = fmt.Errorf("%w (ssh server close failed: %v)", err, closeErr)
+		}
+		return fmt.Errorf("upsert ssh config block failed: %w", err)
+	}
+	fmt.Printf("Added csfs entry for %s to %s, connect with: ssh %s\n", codespace.Name, configPath, codespace.Name)
+	return nil
+}
+
+func sshConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("user home dir failed: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+// sshTunnelLogPath returns where the detached tunnel started by ConfigSSH
+// logs the `gh cs ssh` output it no longer pipes back into this process
+// (see newDetachedSSHServer), creating ~/.ssh if needed.
+func sshTunnelLogPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("user home dir failed: %w", err)
+	}
+	dir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("mkdir ssh dir failed: %w", err)
+	}
+	return filepath.Join(dir, fmt.Sprintf("csfs-%s-tunnel.log", name)), nil
+}
+
+func sshConfigBlock(name string, conn sshServerConn) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s\n", sshConfigBeginPrefix, name)
+	fmt.Fprintf(&b, "Host %s\n", name)
+	fmt.Fprintf(&b, "  HostName localhost\n")
+	fmt.Fprintf(&b, "  Port %d\n", conn.Port)
+	fmt.Fprintf(&b, "  User %s\n", conn.Username)
+	fmt.Fprintf(&b, "  NoHostAuthenticationForLocalhost yes\n")
+	fmt.Fprintf(&b, "  ServerAliveInterval 30\n")
+	fmt.Fprintf(&b, "  ServerAliveCountMax 3\n")
+	fmt.Fprintf(&b, "%s%s\n", sshConfigEndPrefix, name)
+	return b.String()
+}
+
+// upsertSSHConfigBlock replaces the managed block for name if it already
+// exists, or appends it to the end of the file otherwise. Unrelated content
+// in the file is left untouched.
+func upsertSSHConfigBlock(path, name, block string) error {
+	existing, err := readOrCreateSSHConfig(path)
+	if err != nil {
+		return err
+	}
+	out, found := replaceSSHConfigBlock(existing, name, block)
+	if !found {
+		if len(out) > 0 && !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		out += block
+	}
+	return writeSSHConfig(path, out)
+}
+
+// removeSSHConfigBlock deletes the managed block for name, if present.
+func removeSSHConfigBlock(path, name string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read ssh config failed: %w", err)
+	}
+	out, _ := replaceSSHConfigBlock(string(existing), name, "")
+	return writeSSHConfig(path, out)
+}
+
+func readOrCreateSSHConfig(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return "", fmt.Errorf("mkdir ssh dir failed: %w", err)
+			}
+			return "", nil
+		}
+		return "", fmt.Errorf("read ssh config failed: %w", err)
+	}
+	return string(b), nil
+}
+
+func writeSSHConfig(path, content string) error {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("write ssh config failed: %w", err)
+	}
+	return nil
+}
+
+// replaceSSHConfigBlock removes the managed block for name (if present) and
+// replaces it in place with replacement, which may be empty to simply strip
+// the block. It reports whether a block for name was found.
+func replaceSSHConfigBlock(content, name, replacement string) (string, bool) {
+	begin := sshConfigBeginPrefix + name
+	end := sshConfigEndPrefix + name
+
+	lines := strings.Split(content, "\n")
+	var out []string
+	found := false
+	inBlock := false
+	for _, line := range lines {
+		switch {
+		case !inBlock && strings.TrimSpace(line) == strings.TrimSpace(begin):
+			inBlock = true
+			found = true
+			continue
+		case inBlock && strings.TrimSpace(line) == strings.TrimSpace(end):
+			inBlock = false
+			if replacement != "" {
+				out = append(out, strings.TrimRight(replacement, "\n"))
+			}
+			continue
+		case inBlock:
+			continue
+		default:
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n"), found
+}
@@ -3,7 +3,9 @@ package csfs
 import (
 	"context"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -17,6 +19,8 @@ func (s syncType) String() string {
 		return "local"
 	case syncTypeLocalWithDeletion:
 		return "local w/ deletion"
+	case syncTypeConflict:
+		return "conflict"
 	default:
 		return "unknown"
 	}
@@ -26,29 +30,91 @@ const (
 	syncTypeCodespace syncType = iota
 	syncTypeLocal
 	syncTypeLocalWithDeletion
+	syncTypeConflict
 )
 
 type syncer struct {
-	port         int64
-	localDir     string
-	codespaceDir string
-	excludes     []string
-	debounce     time.Duration
+	localDir      string
+	codespaceDir  string
+	excludes      []string
+	debounce      time.Duration
+	conflictMode  ConflictMode
+	transportKind TransportKind
+	username      []byte
+
+	portMu    sync.Mutex
+	port      int64
+	transport Transport
+
+	manifest manifest
+
+	conflictsMu sync.Mutex
+	conflicts   []conflict
+
+	dirtyMu sync.Mutex
+	dirty   map[string]struct{}
 
 	syncToCodespace chan struct{}
 	syncEvent       chan syncType
 }
 
-func newSyncer(port int64, localDir, codespaceDir string, excludes []string, debounce time.Duration) *syncer {
+func newSyncer(
+	port int64, localDir, codespaceDir string, excludes []string, debounce time.Duration,
+	conflictMode ConflictMode, transportKind TransportKind, username []byte,
+) (*syncer, error) {
+	m, err := loadManifest(localDir)
+	if err != nil {
+		// A missing or corrupt manifest shouldn't block startup; treat it as empty
+		// and let the next successful sync rebuild it.
+		m = manifest{}
+	}
+	transport, err := newTransport(transportKind, port, username)
+	if err != nil {
+		return nil, fmt.Errorf("new transport failed: %w", err)
+	}
 	return &syncer{
-		port:            port,
 		localDir:        localDir,
 		codespaceDir:    codespaceDir,
 		excludes:        excludes,
 		debounce:        debounce,
+		conflictMode:    conflictMode,
+		transportKind:   transportKind,
+		username:        username,
+		port:            port,
+		transport:       transport,
+		manifest:        m,
+		dirty:           make(map[string]struct{}),
 		syncToCodespace: make(chan struct{}),
 		syncEvent:       make(chan syncType),
+	}, nil
+}
+
+// UpdatePort rebuilds the transport against a new tunnel port, e.g. after the
+// SSH server reconnects following a dropped connection. Safe to call while a
+// sync is in progress; each step of an in-flight sync (conflict detection,
+// conflict resolution, the transfer itself) uses whichever transport is
+// current when that step starts.
+func (s *syncer) UpdatePort(port int64) error {
+	transport, err := newTransport(s.transportKind, port, s.username)
+	if err != nil {
+		return fmt.Errorf("new transport failed: %w", err)
 	}
+
+	s.portMu.Lock()
+	defer s.portMu.Unlock()
+
+	s.port = port
+	s.transport = transport
+	return nil
+}
+
+// currentTransport returns the transport currently in use, safe for
+// concurrent use with UpdatePort.
+func (s *syncer) currentTransport() Transport {
+	s.portMu.Lock()
+	defer s.portMu.Unlock()
+
+	return s.transport
 }
 
 func (s *syncer) Event() <-chan syncType {
@@ -56,7 +122,15 @@ func (s *syncer) Event() <-chan syncType {
 }
 
 func (s *syncer) SyncToLocal(ctx context.Context, deleteFiles bool) error {
-	return s.sync(ctx, s.codespaceDir, s.localDir, s.excludes, deleteFiles)
+	return s.sync(ctx, s.codespaceDir, s.localDir, s.excludes, deleteFiles, nil)
+}
+
+// InitialSync clones the codespace workspace to localDir for the first time,
+// i.e. when the caller found no local workspace to sync against. It behaves
+// like SyncToLocal but never deletes, since there's nothing local yet for a
+// deletion pass to act on.
+func (s *syncer) InitialSync(ctx context.Context) error {
+	return s.sync(ctx, s.codespaceDir, s.localDir, s.excludes, false, nil)
 }
 
 func (s *syncer) SyncToCodespace(ctx context.Context) {
@@ -66,6 +140,35 @@ func (s *syncer) SyncToCodespace(ctx context.Context) {
 	}
 }
 
+// MarkDirty records paths (relative to localDir) that changed since the last
+// sync to the codespace, so the next SyncToCodespace run can be scoped to
+// just that subtree instead of walking the whole tree.
+func (s *syncer) MarkDirty(relPaths ...string) {
+	s.dirtyMu.Lock()
+	defer s.dirtyMu.Unlock()
+
+	for _, p := range relPaths {
+		s.dirty[p] = struct{}{}
+	}
+}
+
+// takeDirty returns and clears the set of paths marked dirty since the last
+// call. A nil return means "no scoping info", i.e. sync the whole tree.
+func (s *syncer) takeDirty() []string {
+	s.dirtyMu.Lock()
+	defer s.dirtyMu.Unlock()
+
+	if len(s.dirty) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(s.dirty))
+	for p := range s.dirty {
+		paths = append(paths, p)
+	}
+	s.dirty = make(map[string]struct{})
+	return paths
+}
+
 func (s *syncer) Sync(ctx context.Context) error {
 	ticker := time.NewTicker(s.debounce)
 	for {
@@ -74,7 +177,7 @@ func (s *syncer) Sync(ctx context.Context) error {
 			return nil
 		case <-ticker.C:
 			<-s.syncToCodespace
-			if err := s.sync(ctx, s.localDir, s.codespaceDir, s.excludes, true); err != nil {
+			if err := s.sync(ctx, s.localDir, s.codespaceDir, s.excludes, true, s.takeDirty()); err != nil {
 				return err
 			}
 		}
@@ -82,27 +185,50 @@ func (s *syncer) Sync(ctx context.Context) error {
 	return nil
 }
 
-func (s *syncer) sync(ctx context.Context, src, dest string, excludePaths []string, deleteFiles bool) error {
-	args := []string{
-		"--archive",
-		"--compress",
-		"--update",
-		"--perms",
-		"--hard-links",
-		"-e",
-		fmt.Sprintf("ssh -p %d -o NoHostAuthenticationForLocalhost=yes -o PasswordAuthentication=no", s.port),
-	}
-	if deleteFiles {
-		args = append(args, "--delete")
-	}
-	for _, exclude := range excludePaths {
-		args = append(args, "--exclude", exclude)
-	}
-	args = append(args, srcDirWithSuffix(src), dest)
-	cmd := exec.CommandContext(ctx, "rsync", args...)
-	if err := cmd.Run(); err != nil {
+// sync transfers src to dest via s.transport. When files is non-empty, only
+// those paths (relative to src) are transferred instead of walking the
+// entire tree; this is how the watcher keeps a burst of edits to a handful
+// of files from re-scanning everything.
+func (s *syncer) sync(ctx context.Context, src, dest string, excludePaths []string, deleteFiles bool, files []string) error {
+	log.Debug(CategorySyncer, "sync starting", "src", src, "dest", dest, "files", len(files), "delete", deleteFiles)
+
+	conflicts, err := s.detectConflicts(ctx)
+	if err != nil {
+		return fmt.Errorf("detect conflicts failed: %w", err)
+	}
+	pushing := dest == s.codespaceDir
+	exclude, unresolved, err := s.resolveConflicts(ctx, conflicts, pushing)
+	if err != nil {
+		return fmt.Errorf("resolve conflicts failed: %w", err)
+	}
+	s.conflictsMu.Lock()
+	s.conflicts = unresolved
+	s.conflictsMu.Unlock()
+	excludePaths = append(excludePaths, exclude...)
+	if len(unresolved) > 0 {
+		select {
+		case s.syncEvent <- syncTypeConflict:
+		default:
+		}
+	}
+
+	err = s.currentTransport().Sync(ctx, TransportSyncOptions{
+		LocalDir:    s.localDir,
+		Src:         src,
+		Dest:        dest,
+		Excludes:    excludePaths,
+		DeleteFiles: deleteFiles,
+		Files:       files,
+	})
+	if err != nil {
 		return err
 	}
+
+	if err := s.recordManifest(); err != nil {
+		return fmt.Errorf("record manifest failed: %w", err)
+	}
+	log.Debug(CategorySyncer, "sync complete", "src", src, "dest", dest)
+
 	t := syncTypeCodespace
 	if dest == s.localDir {
 		t = syncTypeLocal
@@ -117,6 +243,27 @@ func (s *syncer) sync(ctx context.Context, src, dest string, excludePaths []stri
 	return nil
 }
 
+// recordManifest snapshots the local tree's mtime+size so the next sync can
+// tell which files changed independently on both sides.
+func (s *syncer) recordManifest() error {
+	m := make(manifest)
+	err := filepath.Walk(s.localDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		m.record(relPath(s.localDir, path), info)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.manifest = m
+	return s.manifest.save(s.localDir)
+}
+
 func srcDirWithSuffix(src string) string {
 	if src[len(src)-1] != '/' {
 		src += "/"
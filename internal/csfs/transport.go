@@ -0,0 +1,78 @@
+package csfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransportKind selects which Transport implementation a syncer uses.
+type TransportKind string
+
+const (
+	// TransportRsync shells out to the system rsync binary over the SSH
+	// tunnel. This is the original, default transport.
+	TransportRsync TransportKind = "rsync"
+	// TransportSFTP is a pure-Go transport that needs no local rsync binary,
+	// built on golang.org/x/crypto/ssh and pkg/sftp.
+	TransportSFTP TransportKind = "sftp"
+)
+
+// TransportSyncOptions describes one sync pass between the local workspace
+// and the codespace. LocalDir is always the local workspace root (even when
+// Src/Dest point the other way, for a codespace-to-local sync); it's where
+// exclude rules (.gitignore, .csfsignore) are read from.
+type TransportSyncOptions struct {
+	LocalDir    string
+	Src         string
+	Dest        string
+	Excludes    []string
+	DeleteFiles bool
+	Files       []string // optional subset of paths, relative to Src; nil means "everything"
+}
+
+// Transport performs the actual file transfer between the local filesystem
+// and the codespace, plus the handful of read-only queries the syncer needs
+// to detect and resolve conflicts without assuming a particular transport.
+type Transport interface {
+	Sync(ctx context.Context, opts TransportSyncOptions) error
+
+	// ChangedFiles reports, without transferring anything, the set of
+	// relative paths that differ between opts.Src and opts.Dest.
+	ChangedFiles(ctx context.Context, opts TransportSyncOptions) (map[string]bool, error)
+
+	// StatRemote returns the modification time of relPath (relative to the
+	// workspace root) on the codespace side, for comparing mtimes under
+	// ConflictModeNewerWins.
+	StatRemote(ctx context.Context, codespaceDir, relPath string) (time.Time, error)
+
+	// ReadRemoteFile returns the contents of relPath (relative to the
+	// workspace root) on the codespace side, for backing up the losing side
+	// of a conflict under ConflictModeRename.
+	ReadRemoteFile(ctx context.Context, codespaceDir, relPath string) ([]byte, error)
+}
+
+// splitCodespaceDir splits a transport dir string of the form
+// "user@localhost:/workspaces/foo" into its user@host and path parts. Dirs
+// with no ":" (already a bare path) are returned as-is.
+func splitCodespaceDir(codespaceDir string) (userHost, path string) {
+	if i := strings.Index(codespaceDir, ":"); i != -1 {
+		return codespaceDir[:i], codespaceDir[i+1:]
+	}
+	return "", codespaceDir
+}
+
+// newTransport builds the Transport selected by kind. An empty kind defaults
+// to TransportRsync, so existing callers that don't set AppOptions.Transport
+// keep their current behavior.
+func newTransport(kind TransportKind, port int64, username []byte) (Transport, error) {
+	switch kind {
+	case "", TransportRsync:
+		return newRsyncTransport(port), nil
+	case TransportSFTP:
+		return newSFTPTransport(port, username), nil
+	default:
+		return nil, fmt.Errorf("unknown transport: %s", kind)
+	}
+}
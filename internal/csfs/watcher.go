@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -13,82 +13,160 @@ import (
 type watcher struct {
 	watcher *fsnotify.Watcher
 	syncer  *syncer
+
+	excluded *ignoreMatcher
+	hasWatch bool
+	included *ignoreMatcher
 }
 
 func newWatcher(s *syncer, watch []string) (*watcher, error) {
-	excludedPathsSet := excludedPathsSet(s.localDir, s.excludes)
-	hasWatch, includedPathsSet := includedPathsSet(s.localDir, watch)
-	w, err := fsnotify.NewWatcher()
+	excluded := excludedPathsSet(s.localDir, s.excludes)
+	hasWatch, included := includedPathsSet(s.localDir, watch)
+	fsw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create watcher: %w", err)
 	}
-	// Recursively travel tree, and collect directories to watch.
-	err = filepath.Walk(s.localDir, func(newPath string, info os.FileInfo, err error) error {
+	w := &watcher{syncer: s, watcher: fsw, excluded: excluded, hasWatch: hasWatch, included: included}
+	if err := w.addRecursive(s.localDir); err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", s.localDir, err)
+	}
+	return w, nil
+}
+
+// excludedPathsSet builds a gitignore-aware matcher for paths that should be
+// skipped by the watcher and by rsync, from the --exclude flag plus any
+// .gitignore/.csfsignore found at the root of dir.
+func excludedPathsSet(dir string, excludes []string) *ignoreMatcher {
+	return newIgnoreMatcher(dir, excludes)
+}
+
+// includedPathsSet builds a matcher for the --watch allowlist, if any was
+// given. The bool return reports whether an allowlist is in effect at all.
+// Unlike excludedPathsSet, this does not also load .gitignore/.csfsignore:
+// an unrelated exclude rule (e.g. "*.tmp") has no business widening what
+// --watch includes.
+func includedPathsSet(dir string, included []string) (bool, *ignoreMatcher) {
+	if len(included) == 0 {
+		return false, nil
+	}
+	return true, newPatternMatcher(dir, included)
+}
+
+// addRecursive walks root and installs an fsnotify watch on every directory
+// that isn't excluded, skipping directories outside the --watch allowlist
+// (if one is set) entirely. It's used both for the initial walk and to pick
+// up subtrees created after startup.
+func (w *watcher) addRecursive(root string) error {
+	return filepath.Walk(root, func(newPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		// Skip excluded paths
-		if _, ok := excludedPathsSet[newPath]; ok {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
+		if !info.IsDir() {
 			return nil
 		}
-		if info.IsDir() {
-			if hasWatch {
-				// Skip directories that are not in the watch list.
-				if _, ok := includedPathsSet[newPath]; !ok {
-					return filepath.SkipDir
-				}
-			}
-			err = w.Add(newPath)
-			if err != nil {
-				return fmt.Errorf("failed to add %s to watcher: %w", newPath, err)
-			}
+		rel := relPath(w.syncer.localDir, newPath)
+		if rel != "." && w.excluded.Match(rel, true) {
+			return filepath.SkipDir
+		}
+		if w.hasWatch && rel != "." && !w.included.Match(rel, true) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(newPath); err != nil {
+			return fmt.Errorf("failed to add %s to watcher: %w", newPath, err)
 		}
 		return nil
 	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to walk %s: %w", s.localDir, err)
-	}
-	return &watcher{syncer: s, watcher: w}, nil
 }
 
-func excludedPathsSet(dir string, excludes []string) map[string]struct{} {
-	excludedPathsSet := make(map[string]struct{})
-	for _, exclude := range excludes {
-		if exclude[0] != '/' {
-			exclude = path.Join(dir, exclude)
+// handleEvent keeps the watch tree in sync with the filesystem: new
+// directories are watched (recursively, in case a whole subtree was created
+// in one go, e.g. `mkdir -p`), and watches on removed or renamed-away paths
+// are torn down so they don't leak.
+func (w *watcher) handleEvent(event fsnotify.Event) error {
+	switch {
+	case event.Op&fsnotify.Create != 0:
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			// Already gone by the time we got to it; nothing to watch.
+			return nil
+		}
+		if info.IsDir() {
+			return w.addRecursive(event.Name)
 		}
-		excludedPathsSet[exclude] = struct{}{}
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		// fsnotify errors if the path was never watched (e.g. it was a file),
+		// which is fine to ignore here.
+		_ = w.watcher.Remove(event.Name)
 	}
-	return excludedPathsSet
+	return nil
 }
 
-func includedPathsSet(dir string, included []string) (bool, map[string]struct{}) {
-	if len(included) == 0 {
-		return false, nil
-	}
-	includePathsSet := make(map[string]struct{})
-	for _, include := range included {
-		if include[0] != '/' {
-			include = path.Join(dir, include)
+// Watch consumes the fsnotify event stream, coalescing bursts of changes
+// through a debounce window and feeding the resulting set of changed paths
+// to the syncer so it can scope the next rsync to just that subtree instead
+// of walking the whole tree.
+func (w *watcher) Watch(ctx context.Context) error {
+	pending := make(map[string]struct{})
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
 		}
-		includePathsSet[include] = struct{}{}
+		paths := make([]string, 0, len(pending))
+		for p := range pending {
+			paths = append(paths, p)
+		}
+		pending = make(map[string]struct{})
+		log.Debug(CategoryWatcher, "flushing debounced changes", "count", len(paths))
+		w.syncer.MarkDirty(paths...)
+		w.syncer.SyncToCodespace(ctx)
 	}
-	return true, includePathsSet
-}
 
-func (w *watcher) Watch(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
-		case <-w.watcher.Events:
-			w.syncer.SyncToCodespace(ctx)
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			if err := w.handleEvent(event); err != nil {
+				return err
+			}
+
+			rel := relPath(w.syncer.localDir, event.Name)
+			isDir := event.Op&fsnotify.Create != 0 && isDirAt(event.Name)
+			if rel == "." || w.excluded.Match(rel, isDir) {
+				continue
+			}
+			if w.hasWatch && !w.included.Match(rel, isDir) {
+				continue
+			}
+
+			log.Debug(CategoryWatcher, "event", "path", rel, "op", event.Op.String())
+			pending[rel] = struct{}{}
+			if timer == nil {
+				timer = time.NewTimer(w.syncer.debounce)
+			} else if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(w.syncer.debounce)
+			timerC = timer.C
+		case <-timerC:
+			flush()
+			timerC = nil
 		case err := <-w.watcher.Errors:
 			return err
 		}
 	}
-	return nil
+}
+
+func isDirAt(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
 }